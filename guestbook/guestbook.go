@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -11,14 +14,33 @@ import (
 
 // Comment represents a single comment in the guestbook
 type Comment struct {
+	ID       int64     `json:"id"`
 	Username string    `json:"username"`
 	Message  string    `json:"message"`
 	Time     time.Time `json:"time"`
+	Length   int64     `json:"length,omitempty"`
 }
 
+// RedisMode selects the topology NewRedisClient connects to.
+type RedisMode string
+
+const (
+	// RedisModeSingle talks to a single redis.Client (the historical default).
+	RedisModeSingle RedisMode = "single"
+	// RedisModeCluster talks to a redis.ClusterClient spread across REDIS_ADDRS.
+	RedisModeCluster RedisMode = "cluster"
+	// RedisModeSentinel talks to a sentinel-managed failover group.
+	RedisModeSentinel RedisMode = "sentinel"
+)
+
+// Cmdable is the subset of the go-redis API RedisClient relies on, satisfied
+// by both *redis.Client and *redis.ClusterClient so the rest of the handlers
+// don't need to care which topology is in play.
+type Cmdable = redis.Cmdable
+
 // RedisClient is a wrapper for Redis client
 type RedisClient struct {
-	client *redis.Client
+	client Cmdable
 }
 
 func main() {
@@ -31,6 +53,9 @@ func main() {
 	// Handle GET requests to retrieve comments
 	http.HandleFunc("/comments", getCommentsHandler(redisClient))
 
+	// Handle DELETE requests to remove a comment by ID
+	http.HandleFunc("/comment/", deleteCommentHandler(redisClient))
+
 	// Serve over HTTPS with TLS certificate and key
 	err := http.ListenAndServeTLS("0.0.0.0:8080", "clustereddb.pem", "clustereddb.key", nil)
 	if err != nil {
@@ -38,19 +63,79 @@ func main() {
 	}
 }
 
-// NewRedisClient creates a new Redis client
+// NewRedisClient creates a new Redis client. By default it connects to a
+// single node at redis-container:6379, matching the original behavior, but
+// it can be pointed at a cluster or a sentinel-managed failover group via
+// environment variables:
+//
+//	REDIS_MODE    single|cluster|sentinel (default "single")
+//	REDIS_ADDRS   comma-separated list of addresses (cluster seed nodes, or
+//	              sentinel addresses when REDIS_MODE=sentinel)
+//	REDIS_MASTER_NAME  sentinel master name (REDIS_MODE=sentinel only)
+//	REDIS_ROUTE_BY_LATENCY  "true" to route cluster reads to the replica
+//	              with the lowest measured latency
+//	REDIS_ROUTE_RANDOMLY    "true" to spread cluster reads randomly across
+//	              replicas instead of always hitting the master
 func NewRedisClient() *RedisClient {
-	// Initialize Redis connection options
-	opt := redis.Options{
-		Addr:     "redis-container:6379",
-		Password: "", // no password set
-		DB:       0,  // use default DB
+	mode := RedisMode(os.Getenv("REDIS_MODE"))
+	if mode == "" {
+		mode = RedisModeSingle
+	}
+
+	addrs := splitAddrs(os.Getenv("REDIS_ADDRS"))
+
+	switch mode {
+	case RedisModeCluster:
+		if len(addrs) == 0 {
+			addrs = []string{"redis-container:6379"}
+		}
+		client := redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:          addrs,
+			Password:       os.Getenv("REDIS_PASSWORD"),
+			RouteByLatency: os.Getenv("REDIS_ROUTE_BY_LATENCY") == "true",
+			RouteRandomly:  os.Getenv("REDIS_ROUTE_RANDOMLY") == "true",
+		})
+		return &RedisClient{client: client}
+	case RedisModeSentinel:
+		if len(addrs) == 0 {
+			addrs = []string{"redis-container:26379"}
+		}
+		client := redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    os.Getenv("REDIS_MASTER_NAME"),
+			SentinelAddrs: addrs,
+			Password:      os.Getenv("REDIS_PASSWORD"),
+			DB:            0,
+		})
+		return &RedisClient{client: client}
+	default:
+		addr := "redis-container:6379"
+		if len(addrs) > 0 {
+			addr = addrs[0]
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: os.Getenv("REDIS_PASSWORD"), // no password set by default
+			DB:       0,                           // use default DB
+		})
+		return &RedisClient{client: client}
 	}
+}
 
-	// Create and return a new Redis client
-	return &RedisClient{
-		client: redis.NewClient(&opt),
+// splitAddrs parses a comma-separated REDIS_ADDRS value into a slice,
+// dropping empty entries. It returns nil when s is empty.
+func splitAddrs(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	addrs := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			addrs = append(addrs, p)
+		}
 	}
+	return addrs
 }
 
 // postCommentHandler handles the POST request to add a comment
@@ -64,7 +149,13 @@ func postCommentHandler(redisClient *RedisClient) http.HandlerFunc {
 			return
 		}
 
-		// Add timestamp to the comment
+		// Assign the comment an ID and a timestamp
+		id, err := redisClient.client.Incr(r.Context(), "comments:seq").Result()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		newComment.ID = id
 		newComment.Time = time.Now()
 
 		// Convert the comment to JSON
@@ -74,9 +165,13 @@ func postCommentHandler(redisClient *RedisClient) http.HandlerFunc {
 			return
 		}
 
-		// Add the comment to Redis
-		err = redisClient.client.LPush(r.Context(), "comments", commentJSON).Err()
-		if err != nil {
+		// Add the comment to Redis, along with its per-comment metadata (the
+		// message length, keyed by ID in the "comments:meta" hash), in a
+		// single pipelined round trip.
+		pipe := redisClient.client.Pipeline()
+		pipe.LPush(r.Context(), "comments", commentJSON)
+		pipe.HSet(r.Context(), "comments:meta", strconv.FormatInt(id, 10), len(newComment.Message))
+		if _, err := pipe.Exec(r.Context()); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -86,26 +181,112 @@ func postCommentHandler(redisClient *RedisClient) http.HandlerFunc {
 	}
 }
 
-// getCommentsHandler handles the GET request to retrieve comments
-func getCommentsHandler(redisClient *RedisClient) http.HandlerFunc {
+// deleteCommentHandler handles the DELETE request to remove a single
+// comment, addressed by the ID in its path (DELETE /comment/{id}). Comments
+// are stored as a Redis list rather than keyed individually, so removing
+// one means scanning the list for the matching entry and LREM-ing it; after
+// that scan, the LREM and the "comments:meta" cleanup run in one pipelined
+// round trip.
+func deleteCommentHandler(redisClient *RedisClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Retrieve the most recent comments from Redis
-		commentsJSON, err := redisClient.client.LRange(r.Context(), "comments", 0, 9).Result()
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		idStr := strings.TrimPrefix(r.URL.Path, "/comment/")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid comment id", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		commentsJSON, err := redisClient.client.LRange(ctx, "comments", 0, -1).Result()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Decode comments from JSON
-		var comments []Comment
+		var match string
 		for _, commentJSON := range commentsJSON {
 			var comment Comment
-			err := json.Unmarshal([]byte(commentJSON), &comment)
-			if err != nil {
+			if err := json.Unmarshal([]byte(commentJSON), &comment); err != nil {
+				continue
+			}
+			if comment.ID == id {
+				match = commentJSON
+				break
+			}
+		}
+		if match == "" {
+			http.Error(w, "comment not found", http.StatusNotFound)
+			return
+		}
+
+		pipe := redisClient.client.Pipeline()
+		remCmd := pipe.LRem(ctx, "comments", 1, match)
+		pipe.HDel(ctx, "comments:meta", strconv.FormatInt(id, 10))
+		if _, err := pipe.Exec(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if remCmd.Val() == 0 {
+			// A concurrent delete already removed this comment between the
+			// LRange above and this LRem.
+			http.Error(w, "comment not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getCommentsHandler handles the GET request to retrieve comments. The
+// metadata lookup targets only the IDs of the comments just fetched, via
+// HMGet, rather than HGetAll-ing the entire "comments:meta" hash: that hash
+// gains one field per POST for the life of the guestbook, so HGetAll would
+// make every GET's Redis payload (and latency) grow with total POSTs ever
+// issued instead of staying proportional to the at-most-10 comments
+// returned. The comment IDs aren't known until the LRange reply is decoded,
+// so this costs a second round trip rather than pipelining with the range
+// lookup.
+func getCommentsHandler(redisClient *RedisClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		commentsJSON, err := redisClient.client.LRange(ctx, "comments", 0, 9).Result()
+		if err != nil && err != redis.Nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		comments := make([]Comment, 0, len(commentsJSON))
+		fields := make([]string, 0, len(commentsJSON))
+		for _, commentJSON := range commentsJSON {
+			var comment Comment
+			if err := json.Unmarshal([]byte(commentJSON), &comment); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			comments = append(comments, comment)
+			fields = append(fields, strconv.FormatInt(comment.ID, 10))
+		}
+
+		if len(fields) > 0 {
+			// Metadata is best-effort: a failed lookup shouldn't fail the
+			// request, it just means no extra annotations are added.
+			if values, err := redisClient.client.HMGet(ctx, "comments:meta", fields...).Result(); err == nil {
+				for i, v := range values {
+					length, ok := v.(string)
+					if !ok {
+						continue
+					}
+					if l, err := strconv.ParseInt(length, 10, 64); err == nil {
+						comments[i].Length = l
+					}
+				}
+			}
 		}
 
 		// Respond with the comments
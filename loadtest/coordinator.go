@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/go-redis/redis/v8"
+)
+
+// Coordination channels for -coordinator/-worker mode. The coordinator
+// publishes a single ControlMessage per run on controlChannel; every -worker
+// subscribed to it runs the described load and publishes a WorkerReport back
+// on resultsChannel. This lets a run scale horizontally across pods/VMs
+// instead of being capped by one machine's CPU and file-descriptor budget.
+// abortChannel carries an AbortMessage that tells every -worker running (or
+// about to run) a given RunID to stop early.
+const (
+	controlChannel = "loadtest:control"
+	resultsChannel = "loadtest:results"
+	abortChannel   = "loadtest:abort"
+
+	// readyPollInterval is how often the coordinator re-checks
+	// PubSubNumSub while waiting for workers to subscribe.
+	readyPollInterval = 200 * time.Millisecond
+)
+
+// ControlMessage is published by the coordinator to start a distributed
+// run. It carries everything a worker needs to reproduce the coordinator's
+// configuration locally: base URL, request volume, thread count, rate and
+// scenario. Each worker runs this configuration in full, so total fleet
+// throughput is roughly NumRequests * number of workers.
+type ControlMessage struct {
+	RunID       string         `json:"run_id"`
+	BaseURL     string         `json:"base_url"`
+	NumRequests int            `json:"num_requests"`
+	Threads     int            `json:"threads"`
+	TargetRate  int            `json:"target_rate"`
+	Config      ScenarioConfig `json:"config"`
+}
+
+// WorkerReport is published by a worker once its share of a run completes.
+// Histograms travel as their raw (value, count) buckets rather than summary
+// percentiles, so the coordinator can merge them into one histogram per
+// operation and report exact percentiles across the whole fleet instead of
+// averaging numbers that were already reduced on each worker.
+type WorkerReport struct {
+	RunID               string             `json:"run_id"`
+	Stats               Stats              `json:"stats"`
+	GetHist             []hdrhistogram.Bar `json:"get_hist"`
+	PostHist            []hdrhistogram.Bar `json:"post_hist"`
+	DeleteHist          []hdrhistogram.Bar `json:"delete_hist"`
+	GetHistCorrected    []hdrhistogram.Bar `json:"get_hist_corrected"`
+	PostHistCorrected   []hdrhistogram.Bar `json:"post_hist_corrected"`
+	DeleteHistCorrected []hdrhistogram.Bar `json:"delete_hist_corrected"`
+}
+
+// AbortMessage is published by the coordinator on abortChannel to stop every
+// -worker running RunID early, e.g. after -coordinator-results-timeout
+// elapses with some workers still unreported.
+type AbortMessage struct {
+	RunID string `json:"run_id"`
+}
+
+// newCoordinationClient builds a go-redis client for -coordinator/-worker
+// Pub/Sub, reusing the same client library the guestbook server depends on.
+func newCoordinationClient(addr string) *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: addr})
+}
+
+// waitForWorkers blocks until numWorkers clients are subscribed to
+// controlChannel, or returns an error once timeout elapses. Redis Pub/Sub
+// has no delivery queue for a channel's past messages, so a worker that
+// subscribes after the coordinator publishes never sees that run; polling
+// PubSubNumSub lets the coordinator confirm every worker is listening
+// first. A timeout <= 0 skips the wait and publishes immediately.
+func waitForWorkers(ctx context.Context, rdb *redis.Client, numWorkers int, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		counts, err := rdb.PubSubNumSub(ctx, controlChannel).Result()
+		if err != nil {
+			return fmt.Errorf("checking worker subscriptions: %w", err)
+		}
+		if counts[controlChannel] >= int64(numWorkers) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return fmt.Errorf("timed out after %s waiting for %d worker(s) to subscribe (%d connected)", timeout, numWorkers, counts[controlChannel])
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// publishAbort tells every -worker running (or about to run) runID to stop
+// early. Delivery is best-effort: a worker that has already finished and
+// exited simply never sees it.
+func publishAbort(ctx context.Context, rdb *redis.Client, runID string) {
+	payload, err := json.Marshal(AbortMessage{RunID: runID})
+	if err != nil {
+		fmt.Println("coordinator: failed to encode abort message:", err)
+		return
+	}
+	if err := rdb.Publish(ctx, abortChannel, payload).Err(); err != nil {
+		fmt.Println("coordinator: failed to publish abort message:", err)
+	}
+}
+
+// runCoordinator waits for -workers workers to subscribe to controlChannel,
+// publishes one ControlMessage describing this run, then blocks until
+// numWorkers WorkerReports for that run ID arrive on resultsChannel (or
+// -coordinator-results-timeout elapses), merging their Stats and histograms
+// as they come in. If the results wait times out or ctx is canceled, it
+// publishes an AbortMessage so any worker that did start stops rather than
+// running unsupervised; if the subscribe wait times out, no run has been
+// published yet and it returns an error without publishing an abort.
+func runCoordinator(ctx context.Context, rdb *redis.Client, numWorkers int, cfg ScenarioConfig) (Stats, *MetricsRecorder, error) {
+	sub := rdb.Subscribe(ctx, resultsChannel)
+	defer sub.Close()
+
+	fmt.Printf("coordinator: waiting up to %s for %d worker(s) to subscribe\n", coordinatorReadyWait, numWorkers)
+	if err := waitForWorkers(ctx, rdb, numWorkers, coordinatorReadyWait); err != nil {
+		return Stats{}, nil, fmt.Errorf("waiting for workers: %w", err)
+	}
+
+	runID := fmt.Sprintf("%s-%d", baseURL, time.Now().UnixNano())
+	msg := ControlMessage{
+		RunID:       runID,
+		BaseURL:     baseURL,
+		NumRequests: numRequests,
+		Threads:     threads,
+		TargetRate:  targetRate,
+		Config:      cfg,
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return Stats{}, nil, fmt.Errorf("encoding control message: %w", err)
+	}
+	if err := rdb.Publish(ctx, controlChannel, payload).Err(); err != nil {
+		return Stats{}, nil, fmt.Errorf("publishing control message: %w", err)
+	}
+	fmt.Printf("coordinator: published run %s, waiting for %d worker(s)\n", runID, numWorkers)
+
+	var resultsDeadline <-chan time.Time
+	if coordinatorResultsWait > 0 {
+		timer := time.NewTimer(coordinatorResultsWait)
+		defer timer.Stop()
+		resultsDeadline = timer.C
+	}
+
+	var total Stats
+	recorder := NewMetricsRecorder()
+	ch := sub.Channel()
+	for received := 0; received < numWorkers; {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return total, recorder, fmt.Errorf("results subscription closed after %d/%d workers reported", received, numWorkers)
+			}
+
+			var report WorkerReport
+			if err := json.Unmarshal([]byte(m.Payload), &report); err != nil {
+				fmt.Println("coordinator: dropping malformed worker report:", err)
+				continue
+			}
+			if report.RunID != runID {
+				continue
+			}
+
+			received++
+			total.SuccessfulGET += report.Stats.SuccessfulGET
+			total.SuccessfulPOST += report.Stats.SuccessfulPOST
+			total.SuccessfulDelete += report.Stats.SuccessfulDelete
+			total.TotalBytesSent += report.Stats.TotalBytesSent
+			total.TotalBytesRecv += report.Stats.TotalBytesRecv
+			total.Errors += report.Stats.Errors
+			recorder.mergeRemote(report.GetHist, report.PostHist, report.DeleteHist, report.GetHistCorrected, report.PostHistCorrected, report.DeleteHistCorrected)
+			fmt.Printf("coordinator: received report %d/%d\n", received, numWorkers)
+		case <-resultsDeadline:
+			publishAbort(ctx, rdb, runID)
+			return total, recorder, fmt.Errorf("timed out after %s waiting for worker reports (%d/%d received), abort published", coordinatorResultsWait, received, numWorkers)
+		case <-ctx.Done():
+			publishAbort(ctx, rdb, runID)
+			return total, recorder, ctx.Err()
+		}
+	}
+	return total, recorder, nil
+}
+
+// runWorker subscribes to controlChannel and abortChannel, waits for a
+// single start message, runs the usual load-generation loop against the
+// coordinator's configuration (stopping early if an AbortMessage for this
+// run arrives), and publishes its Stats and histograms back on
+// resultsChannel.
+func runWorker(ctx context.Context, rdb *redis.Client) error {
+	sub := rdb.Subscribe(ctx, controlChannel, abortChannel)
+	defer sub.Close()
+
+	fmt.Println("worker: waiting for a run from the coordinator...")
+	ch := sub.Channel()
+
+	var ctrl ControlMessage
+	for {
+		m, ok := <-ch
+		if !ok {
+			return fmt.Errorf("control subscription closed before a run arrived")
+		}
+		if m.Channel != controlChannel {
+			// A stray abort for some earlier/other run; nothing to stop yet.
+			continue
+		}
+		if err := json.Unmarshal([]byte(m.Payload), &ctrl); err != nil {
+			return fmt.Errorf("decoding control message: %w", err)
+		}
+		break
+	}
+
+	baseURL = ctrl.BaseURL
+	numRequests = ctrl.NumRequests
+	threads = ctrl.Threads
+	targetRate = ctrl.TargetRate
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		for m := range ch {
+			if m.Channel != abortChannel {
+				continue
+			}
+			var ab AbortMessage
+			if err := json.Unmarshal([]byte(m.Payload), &ab); err != nil {
+				continue
+			}
+			if ab.RunID == ctrl.RunID {
+				fmt.Printf("worker: received abort for run %s, stopping\n", ctrl.RunID)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("worker: starting run %s (%d requests across %d threads against %s)\n", ctrl.RunID, numRequests, threads, baseURL)
+	stats, recorder, elapsed := runLoad(runCtx, ctrl.Config)
+	fmt.Printf("worker: finished run %s in %s\n", ctrl.RunID, elapsed)
+
+	getHist, postHist, deleteHist := recorder.snapshot()
+	getHistCorrected, postHistCorrected, deleteHistCorrected := recorder.snapshotCorrected()
+	report := WorkerReport{
+		RunID:               ctrl.RunID,
+		Stats:               stats,
+		GetHist:             histogramBuckets(getHist),
+		PostHist:            histogramBuckets(postHist),
+		DeleteHist:          histogramBuckets(deleteHist),
+		GetHistCorrected:    histogramBuckets(getHistCorrected),
+		PostHistCorrected:   histogramBuckets(postHistCorrected),
+		DeleteHistCorrected: histogramBuckets(deleteHistCorrected),
+	}
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encoding worker report: %w", err)
+	}
+	if err := rdb.Publish(ctx, resultsChannel, payload).Err(); err != nil {
+		return fmt.Errorf("publishing worker report: %w", err)
+	}
+	fmt.Printf("worker: reported run %s\n", ctrl.RunID)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// newHTTPClient builds a worker-local *http.Client from the -cacert/-cert/
+// -key/-insecure/-max-idle-conns-per-host/-disable-keepalives/-disable-http2
+// flags. Each worker gets its own Transport (and so its own connection
+// pool) rather than sharing one across the fleet of goroutines, matching
+// the load client's one-goroutine-per-thread design.
+func newHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+
+	if caCertPath != "" {
+		pem, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading -cacert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in -cacert %s", caCertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, fmt.Errorf("-cert and -key must both be set for client certificate auth")
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading -cert/-key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		DisableKeepAlives:   disableKeepAlives,
+		ForceAttemptHTTP2:   !disableHTTP2,
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// applyAuth sets the Authorization header on req per the -auth-bearer/
+// -auth-basic flags, preferring a bearer token over basic auth when both
+// are configured. It is a no-op when neither flag is set.
+func applyAuth(req *http.Request) {
+	switch {
+	case authBearer != "":
+		req.Header.Set("Authorization", "Bearer "+authBearer)
+	case authBasic != "":
+		user, pass, _ := strings.Cut(authBasic, ":")
+		req.SetBasicAuth(user, pass)
+	}
+}
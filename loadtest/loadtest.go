@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,23 +10,46 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
-	"sort"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 var (
-	baseURL     string
-	numRequests int
-	threads     int
+	baseURL          string
+	numRequests      int
+	threads          int
+	scenarioPath     string
+	snapshotInterval time.Duration
+	metricsAddr      string
+	hgrmOut          string
+	targetRate       int
+
+	coordinatorMode        bool
+	workerMode             bool
+	coordinationRedisAddr  string
+	expectedWorkers        int
+	coordinatorReadyWait   time.Duration
+	coordinatorResultsWait time.Duration
+
+	caCertPath          string
+	certPath            string
+	keyPath             string
+	insecureSkipVerify  bool
+	authBearer          string
+	authBasic           string
+	maxIdleConnsPerHost int
+	disableKeepAlives   bool
+	disableHTTP2        bool
 )
 
 type Stats struct {
-	SuccessfulGET  int
-	SuccessfulPOST int
-	TotalBytesSent int64
-	TotalBytesRecv int64
-	Errors         int
-	Latencies      []time.Duration
+	SuccessfulGET    int
+	SuccessfulPOST   int
+	SuccessfulDelete int
+	TotalBytesSent   int64
+	TotalBytesRecv   int64
+	Errors           int
 }
 
 // Comment represents a single comment in the guestbook
@@ -39,156 +63,402 @@ func init() {
 	flag.StringVar(&baseURL, "host", "http://your_guestbook_url.com", "Host and port of the guestbook app")
 	flag.IntVar(&numRequests, "n", 1000, "Number of requests")
 	flag.IntVar(&threads, "threads", 10, "Number of concurrent request threads")
+	flag.StringVar(&scenarioPath, "scenario", "", "Path to a YAML/JSON scenario file describing op weights, RPS, payload size and think time (defaults to a 50/50 GET/POST mix with no pacing)")
+	flag.DurationVar(&snapshotInterval, "snapshot-interval", 5*time.Second, "How often to print rolling latency percentiles during the run")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve a Prometheus /metrics endpoint on (e.g. :9090); disabled when empty")
+	flag.StringVar(&hgrmOut, "hgrm-out", "", "Path to write the final latency histograms in HGRM format; skipped when empty")
+	flag.IntVar(&targetRate, "rate", 0, "Target requests/sec across all threads, scheduled against a fixed-rate clock instead of firing the next request as soon as the last one returns; when set, latency is additionally recorded corrected for coordinated omission (actual finish time minus the request's intended start, not its actual start), overriding the scenario file's own rps pacing")
+	flag.BoolVar(&coordinatorMode, "coordinator", false, "Run as the controller for a distributed run: publish this process's -host/-n/-threads/-rate/-scenario config to -workers worker instances over Redis Pub/Sub and print their combined Stats and histograms once they all report back")
+	flag.BoolVar(&workerMode, "worker", false, "Run as a worker for a distributed run: wait for a single start message from a -coordinator instance over Redis Pub/Sub, run the load it describes, and publish Stats and histograms back")
+	flag.StringVar(&coordinationRedisAddr, "coordinator-redis", "redis-container:6379", "Redis address used for -coordinator/-worker Pub/Sub coordination")
+	flag.IntVar(&expectedWorkers, "workers", 1, "Number of worker instances a -coordinator run should wait for before aggregating and printing results")
+	flag.DurationVar(&coordinatorReadyWait, "coordinator-ready-timeout", 30*time.Second, "How long a -coordinator waits for -workers worker(s) to subscribe before publishing the run; since Redis Pub/Sub drops messages published before a subscriber connects, a worker that subscribes after this wait never sees the run")
+	flag.DurationVar(&coordinatorResultsWait, "coordinator-results-timeout", 30*time.Minute, "How long a -coordinator waits for worker reports after publishing a run before giving up and aborting it; raise this for runs that legitimately take longer")
+	flag.StringVar(&caCertPath, "cacert", "", "Path to a PEM CA certificate to trust in addition to the system pool, for validating the server's TLS certificate")
+	flag.StringVar(&certPath, "cert", "", "Path to a PEM client certificate, for mutual TLS; requires -key")
+	flag.StringVar(&keyPath, "key", "", "Path to the PEM private key matching -cert")
+	flag.BoolVar(&insecureSkipVerify, "insecure", false, "Skip TLS certificate verification")
+	flag.StringVar(&authBearer, "auth-bearer", "", "Bearer token sent as the Authorization header on every request")
+	flag.StringVar(&authBasic, "auth-basic", "", "HTTP Basic auth credentials, as user:password, sent on every request (ignored if -auth-bearer is also set)")
+	flag.IntVar(&maxIdleConnsPerHost, "max-idle-conns-per-host", 100, "Max idle keep-alive connections per host, per worker's HTTP client")
+	flag.BoolVar(&disableKeepAlives, "disable-keepalives", false, "Disable HTTP keep-alives, opening a fresh connection for every request")
+	flag.BoolVar(&disableHTTP2, "disable-http2", false, "Disable HTTP/2, even when the server supports it over TLS")
 	flag.Parse()
 }
 
 func main() {
+	cfg := defaultScenarioConfig()
+	if scenarioPath != "" {
+		loaded, err := loadScenarioConfig(scenarioPath)
+		if err != nil {
+			fmt.Println("Error loading scenario file:", err)
+			return
+		}
+		cfg = loaded
+	}
+
+	switch {
+	case workerMode:
+		rdb := newCoordinationClient(coordinationRedisAddr)
+		defer rdb.Close()
+		if err := runWorker(context.Background(), rdb); err != nil {
+			fmt.Println("Error running worker:", err)
+		}
+	case coordinatorMode:
+		rdb := newCoordinationClient(coordinationRedisAddr)
+		defer rdb.Close()
+		startTime := time.Now()
+		total, recorder, err := runCoordinator(context.Background(), rdb, expectedWorkers, cfg)
+		if err != nil {
+			fmt.Println("Error coordinating run:", err)
+			return
+		}
+		printReport(total, recorder, time.Since(startTime))
+		writeHGRMIfConfigured(recorder)
+	default:
+		total, recorder, elapsed := runLoad(context.Background(), cfg)
+		printReport(total, recorder, elapsed)
+		writeHGRMIfConfigured(recorder)
+	}
+}
+
+// runLoad builds a Scenario from cfg and runs -n requests across -threads
+// goroutines, returning the combined Stats and the MetricsRecorder that
+// captured every latency sample. It is shared by plain single-process runs
+// and by each -worker instance, which runs it against the coordinator's cfg
+// instead of its own. Canceling ctx (e.g. on an abort message from the
+// -coordinator) stops each thread before it starts its next request and
+// returns whatever Stats were accumulated so far.
+func runLoad(ctx context.Context, cfg ScenarioConfig) (Stats, *MetricsRecorder, time.Duration) {
+	scenario, err := NewScenario(cfg)
+	if err != nil {
+		fmt.Println("Error building scenario:", err)
+		return Stats{}, NewMetricsRecorder(), 0
+	}
+
+	// A nil limiter means closed-loop: no pacing between requests. -rate
+	// takes priority over the scenario's own RPS when both are set, since
+	// it additionally drives coordinated-omission-corrected recording.
+	var limiter *rate.Limiter
+	if targetRate == 0 && cfg.RPS > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RPS), cfg.RPS)
+	}
+
+	var pacerInterval time.Duration
+	if targetRate > 0 {
+		pacerInterval = time.Duration(float64(threads) * float64(time.Second) / float64(targetRate))
+	}
+
+	recorder := NewMetricsRecorder()
+	stopSnapshots := make(chan struct{})
+	recorder.StartSnapshotting(snapshotInterval, stopSnapshots)
+	defer close(stopSnapshots)
+
+	if metricsAddr != "" {
+		ServeMetrics(metricsAddr, recorder)
+	}
+
 	startTime := time.Now()
 	statsChan := make(chan Stats, threads)
 	requestsPerThread := numRequests / threads
 
 	for i := 0; i < threads; i++ {
-		go makeRequests(requestsPerThread, statsChan)
+		var pacer *Pacer
+		if pacerInterval > 0 {
+			pacer = NewPacer(pacerInterval)
+		}
+		// Each worker gets its own *http.Client (and so its own connection
+		// pool), rather than sharing one across the fleet of goroutines.
+		client, err := newHTTPClient()
+		if err != nil {
+			fmt.Println("Error building HTTP client:", err)
+			return Stats{}, recorder, 0
+		}
+		go makeRequests(ctx, requestsPerThread, scenario, cfg, limiter, pacer, client, recorder, statsChan)
 	}
 
-	var (
-		successfulGET  = 0
-		successfulPOST = 0
-		totalBytesSent int64
-		totalBytesRecv int64
-		errors         = 0
-		latencies      []time.Duration
-	)
-
+	var total Stats
 	for i := 0; i < threads; i++ {
 		s := <-statsChan
-		successfulGET += s.SuccessfulGET
-		successfulPOST += s.SuccessfulPOST
-		totalBytesSent += s.TotalBytesSent
-		totalBytesRecv += s.TotalBytesRecv
-		errors += s.Errors
-		latencies = append(latencies, s.Latencies...)
-	}
-
-	elapsedTime := time.Since(startTime)
-
-	// Calculate percentiles for latencies
-	latenciesSorted := make([]time.Duration, len(latencies))
-	copy(latenciesSorted, latencies)
-
-	// Sort latencies
-	sortDurationSlice(latenciesSorted)
-
-	percentiles := []int{50, 90, 95, 99}
-	percentileLatencies := make(map[int]time.Duration)
-	for _, p := range percentiles {
-		idx := (len(latenciesSorted) * p) / 100
-		percentileLatencies[p] = latenciesSorted[idx]
-	}
-
-	// Calculate requests per second
-	requestsPerSecond := float64(numRequests) / elapsedTime.Seconds()
-
-	// Print statistics
-	fmt.Println("Elapsed Time:", elapsedTime)
-	fmt.Println("Total Bytes Sent:", totalBytesSent)
-	fmt.Println("Total Bytes Received:", totalBytesRecv)
-	fmt.Println("Successful GET Requests:", successfulGET)
-	fmt.Println("Successful POST Requests:", successfulPOST)
-	fmt.Println("Total Errors:", errors)
-	fmt.Println("50th Percentile Latency:", percentileLatencies[50])
-	fmt.Println("90th Percentile Latency:", percentileLatencies[90])
-	fmt.Println("95th Percentile Latency:", percentileLatencies[95])
-	fmt.Println("99th Percentile Latency:", percentileLatencies[99])
+		total.SuccessfulGET += s.SuccessfulGET
+		total.SuccessfulPOST += s.SuccessfulPOST
+		total.SuccessfulDelete += s.SuccessfulDelete
+		total.TotalBytesSent += s.TotalBytesSent
+		total.TotalBytesRecv += s.TotalBytesRecv
+		total.Errors += s.Errors
+	}
+
+	return total, recorder, time.Since(startTime)
+}
+
+// printReport prints the combined Stats and latency percentiles for a run,
+// whether it came from a single process or was aggregated by -coordinator
+// across a fleet of -worker instances.
+func printReport(total Stats, recorder *MetricsRecorder, elapsed time.Duration) {
+	getHist, postHist, deleteHist := recorder.snapshot()
+
+	totalRequests := total.SuccessfulGET + total.SuccessfulPOST + total.SuccessfulDelete + total.Errors
+	requestsPerSecond := float64(totalRequests) / elapsed.Seconds()
+
+	fmt.Println("Elapsed Time:", elapsed)
+	fmt.Println("Total Bytes Sent:", total.TotalBytesSent)
+	fmt.Println("Total Bytes Received:", total.TotalBytesRecv)
+	fmt.Println("Successful GET Requests:", total.SuccessfulGET)
+	fmt.Println("Successful POST Requests:", total.SuccessfulPOST)
+	fmt.Println("Successful DELETE Requests:", total.SuccessfulDelete)
+	fmt.Println("Total Errors:", total.Errors)
+	fmt.Println("GET p50 Latency:", microDuration(getHist.ValueAtQuantile(50)))
+	fmt.Println("GET p90 Latency:", microDuration(getHist.ValueAtQuantile(90)))
+	fmt.Println("GET p95 Latency:", microDuration(getHist.ValueAtQuantile(95)))
+	fmt.Println("GET p99 Latency:", microDuration(getHist.ValueAtQuantile(99)))
+	fmt.Println("POST p50 Latency:", microDuration(postHist.ValueAtQuantile(50)))
+	fmt.Println("POST p90 Latency:", microDuration(postHist.ValueAtQuantile(90)))
+	fmt.Println("POST p95 Latency:", microDuration(postHist.ValueAtQuantile(95)))
+	fmt.Println("POST p99 Latency:", microDuration(postHist.ValueAtQuantile(99)))
+	fmt.Println("DELETE p50 Latency:", microDuration(deleteHist.ValueAtQuantile(50)))
+	fmt.Println("DELETE p90 Latency:", microDuration(deleteHist.ValueAtQuantile(90)))
+	fmt.Println("DELETE p95 Latency:", microDuration(deleteHist.ValueAtQuantile(95)))
+	fmt.Println("DELETE p99 Latency:", microDuration(deleteHist.ValueAtQuantile(99)))
 	fmt.Println("Requests per Second:", requestsPerSecond)
+
+	getCorrected, postCorrected, deleteCorrected := recorder.snapshotCorrected()
+	if getCorrected.TotalCount() > 0 || postCorrected.TotalCount() > 0 || deleteCorrected.TotalCount() > 0 {
+		// The percentiles above are "uncorrected": actual_finish -
+		// actual_start, measured per request in isolation. Under -rate
+		// pacing, a worker that falls behind during a slow response still
+		// owes requests to the schedule it missed, and those go unrecorded
+		// by the uncorrected numbers — the coordinated-omission problem.
+		// The figures below are "corrected": actual_finish - intended_start,
+		// the time a fixed-rate client would actually have experienced for
+		// that slot. Prefer the corrected numbers when judging tail latency
+		// under sustained load.
+		fmt.Println("--- Coordinated-omission corrected (actual_finish - intended_start) ---")
+		fmt.Println("GET p50 Latency (corrected):", microDuration(getCorrected.ValueAtQuantile(50)))
+		fmt.Println("GET p90 Latency (corrected):", microDuration(getCorrected.ValueAtQuantile(90)))
+		fmt.Println("GET p95 Latency (corrected):", microDuration(getCorrected.ValueAtQuantile(95)))
+		fmt.Println("GET p99 Latency (corrected):", microDuration(getCorrected.ValueAtQuantile(99)))
+		fmt.Println("GET p99.9 Latency (corrected):", microDuration(getCorrected.ValueAtQuantile(99.9)))
+		fmt.Println("POST p50 Latency (corrected):", microDuration(postCorrected.ValueAtQuantile(50)))
+		fmt.Println("POST p90 Latency (corrected):", microDuration(postCorrected.ValueAtQuantile(90)))
+		fmt.Println("POST p95 Latency (corrected):", microDuration(postCorrected.ValueAtQuantile(95)))
+		fmt.Println("POST p99 Latency (corrected):", microDuration(postCorrected.ValueAtQuantile(99)))
+		fmt.Println("POST p99.9 Latency (corrected):", microDuration(postCorrected.ValueAtQuantile(99.9)))
+		fmt.Println("DELETE p50 Latency (corrected):", microDuration(deleteCorrected.ValueAtQuantile(50)))
+		fmt.Println("DELETE p90 Latency (corrected):", microDuration(deleteCorrected.ValueAtQuantile(90)))
+		fmt.Println("DELETE p95 Latency (corrected):", microDuration(deleteCorrected.ValueAtQuantile(95)))
+		fmt.Println("DELETE p99 Latency (corrected):", microDuration(deleteCorrected.ValueAtQuantile(99)))
+		fmt.Println("DELETE p99.9 Latency (corrected):", microDuration(deleteCorrected.ValueAtQuantile(99.9)))
+	}
+}
+
+func writeHGRMIfConfigured(recorder *MetricsRecorder) {
+	if hgrmOut == "" {
+		return
+	}
+	if err := WriteHGRM(hgrmOut, recorder); err != nil {
+		fmt.Println("Error writing HGRM output:", err)
+	}
 }
 
-func makeRequests(requestsPerThread int, statsChan chan Stats) {
+// makeRequests runs requestsPerThread operations drawn from scenario,
+// pacing against pacer (when set, for coordinated-omission-corrected
+// recording) or limiter (closed-loop-avoiding but uncorrected), recording
+// latencies into recorder, and sleeping for a random think time between
+// requests (when cfg.ThinkTime is configured). It stops early, returning
+// whatever Stats were accumulated so far, if ctx is canceled between
+// requests.
+func makeRequests(ctx context.Context, requestsPerThread int, scenario *Scenario, cfg ScenarioConfig, limiter *rate.Limiter, pacer *Pacer, client *http.Client, recorder *MetricsRecorder, statsChan chan Stats) {
 	var s Stats
 	for i := 0; i < requestsPerThread; i++ {
-		// Generate random query parameter
-		queryKey := randomString(10)
-		queryValue := randomString(10)
-
-		// Choose randomly between GET and POST
-		if rand.Intn(2) == 0 {
-			// Perform GET request with exponential backoff
-			resp, latency, bytesSent, bytesRecv, err := exponentialBackoffGET(fmt.Sprintf("%s/comments?%s=%s", baseURL, queryKey, queryValue))
-			if err != nil {
-				fmt.Println("GET request error:", err)
-				s.Errors++
-				continue
-			}
-			s.TotalBytesSent += bytesSent
-			s.TotalBytesRecv += bytesRecv
-			s.Latencies = append(s.Latencies, latency)
-			defer resp.Body.Close()
-
-			s.SuccessfulGET++
-		} else {
-			// Generate random comment string for POST request
-			comment := randomString(30)
-
-			// Perform POST request with exponential backoff
-			c := Comment{Time: time.Now(), Username: "test", Message: comment}
-			resp, latency, bytesSent, bytesRecv, err := exponentialBackoffPOST(fmt.Sprintf("%s/comment?%s=%s", baseURL, queryKey, queryValue), c)
-			if err != nil {
-				fmt.Println("POST request error:", err)
-				s.Errors++
-				continue
-			}
-			s.TotalBytesSent += bytesSent
-			s.TotalBytesRecv += bytesRecv
-			s.Latencies = append(s.Latencies, latency)
-			defer resp.Body.Close()
+		if ctx.Err() != nil {
+			break
+		}
+
+		var intendedStart time.Time
+		switch {
+		case pacer != nil:
+			intendedStart = pacer.Wait()
+		case limiter != nil:
+			limiter.Wait(ctx)
+		}
 
-			s.SuccessfulPOST++
+		switch scenario.Next() {
+		case OpGetComments:
+			performGet(client, fmt.Sprintf("%s/comments", baseURL), recorder, &s, intendedStart)
+		case OpPaginate:
+			cursor := rand.Intn(100)
+			performGet(client, fmt.Sprintf("%s/comments?cursor=%d", baseURL, cursor), recorder, &s, intendedStart)
+		case OpSearch:
+			performGet(client, fmt.Sprintf("%s/comments?q=%s", baseURL, randomString(8)), recorder, &s, intendedStart)
+		case OpPostComment:
+			performPost(client, cfg, recorder, &s, intendedStart)
+		case OpDelete:
+			performDelete(client, fmt.Sprintf("%s/comment/%d", baseURL, rand.Intn(1000)), recorder, &s, intendedStart)
 		}
+
+		thinkTime(cfg)
 	}
 	statsChan <- s
 }
 
-func exponentialBackoffGET(url string) (*http.Response, time.Duration, int64, int64, error) {
-	backoff := 20 * time.Millisecond
-	var latency time.Duration
-	var requestSize, responseSize int64
-	for i := 0; i < 10; i++ {
-		start := time.Now()
-		resp, err := http.Get(url)
-		latency = time.Since(start)
-		if err == nil {
-			// Calculate request and response sizes
-			requestSize = 0 // No request body in GET request
-			if resp != nil && resp.Body != nil {
-				responseSize, _ = io.Copy(ioutil.Discard, resp.Body)
-			}
-			return resp, latency, requestSize, responseSize, nil
-		}
-		time.Sleep(backoff)
-		backoff *= 2
-		if backoff > 5*time.Second {
-			backoff = 5 * time.Second
-		}
+// thinkTime sleeps for a random duration within cfg.ThinkTime, simulating a
+// user pausing between actions. It is a no-op when ThinkTime is unset.
+func thinkTime(cfg ScenarioConfig) {
+	if cfg.ThinkTime.Max <= 0 {
+		return
 	}
-	return nil, 0, 0, 0, fmt.Errorf("exponential backoff exceeded")
+	min, max := cfg.ThinkTime.Min, cfg.ThinkTime.Max
+	if max < min {
+		max = min
+	}
+	ms := min
+	if max > min {
+		ms += rand.Intn(max - min + 1)
+	}
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+}
+
+// randomPayloadSize picks a comment body length within cfg.PayloadSize.
+func randomPayloadSize(cfg ScenarioConfig) int {
+	min, max := cfg.PayloadSize.Min, cfg.PayloadSize.Max
+	if min <= 0 && max <= 0 {
+		return 30
+	}
+	if max < min {
+		max = min
+	}
+	if max == min {
+		return min
+	}
+	return min + rand.Intn(max-min+1)
+}
+
+// isSuccessStatus reports whether code is a 2xx HTTP status.
+func isSuccessStatus(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// performGet issues a GET and records its latency. When intendedStart is
+// non-zero (i.e. -rate pacing is active), it also records the
+// coordinated-omission-corrected latency: actual_finish - intended_start.
+// A non-2xx response counts as an error rather than a success, the same as
+// a transport-level failure.
+func performGet(client *http.Client, url string, recorder *MetricsRecorder, s *Stats, intendedStart time.Time) {
+	resp, latency, bytesSent, bytesRecv, err := exponentialBackoffGET(client, url)
+	finish := time.Now()
+	if err != nil {
+		fmt.Println("GET request error:", err)
+		s.Errors++
+		return
+	}
+	defer resp.Body.Close()
+	if !isSuccessStatus(resp.StatusCode) {
+		fmt.Printf("GET request failed: %s returned %d\n", url, resp.StatusCode)
+		s.Errors++
+		return
+	}
+
+	s.TotalBytesSent += bytesSent
+	s.TotalBytesRecv += bytesRecv
+	recorder.RecordGet(latency)
+	if !intendedStart.IsZero() {
+		recorder.RecordGetCorrected(finish.Sub(intendedStart))
+	}
+
+	s.SuccessfulGET++
 }
 
-func exponentialBackoffPOST(url string, comment Comment) (*http.Response, time.Duration, int64, int64, error) {
+func performPost(client *http.Client, cfg ScenarioConfig, recorder *MetricsRecorder, s *Stats, intendedStart time.Time) {
+	comment := randomString(randomPayloadSize(cfg))
+	c := Comment{Time: time.Now(), Username: "test", Message: comment}
+
+	resp, latency, bytesSent, bytesRecv, err := exponentialBackoffPOST(client, fmt.Sprintf("%s/comment", baseURL), c)
+	finish := time.Now()
+	if err != nil {
+		fmt.Println("POST request error:", err)
+		s.Errors++
+		return
+	}
+	defer resp.Body.Close()
+	if !isSuccessStatus(resp.StatusCode) {
+		fmt.Printf("POST request failed: %s returned %d\n", baseURL+"/comment", resp.StatusCode)
+		s.Errors++
+		return
+	}
+
+	s.TotalBytesSent += bytesSent
+	s.TotalBytesRecv += bytesRecv
+	recorder.RecordPost(latency)
+	if !intendedStart.IsZero() {
+		recorder.RecordPostCorrected(finish.Sub(intendedStart))
+	}
+
+	s.SuccessfulPOST++
+}
+
+func performDelete(client *http.Client, url string, recorder *MetricsRecorder, s *Stats, intendedStart time.Time) {
+	resp, latency, bytesSent, bytesRecv, err := exponentialBackoffDo(client, http.MethodDelete, url, nil)
+	finish := time.Now()
+	if err != nil {
+		fmt.Println("DELETE request error:", err)
+		s.Errors++
+		return
+	}
+	defer resp.Body.Close()
+	if !isSuccessStatus(resp.StatusCode) {
+		fmt.Printf("DELETE request failed: %s returned %d\n", url, resp.StatusCode)
+		s.Errors++
+		return
+	}
+
+	s.TotalBytesSent += bytesSent
+	s.TotalBytesRecv += bytesRecv
+	recorder.RecordDelete(latency)
+	if !intendedStart.IsZero() {
+		recorder.RecordDeleteCorrected(finish.Sub(intendedStart))
+	}
+
+	s.SuccessfulDelete++
+}
+
+func exponentialBackoffGET(client *http.Client, url string) (*http.Response, time.Duration, int64, int64, error) {
+	return exponentialBackoffDo(client, http.MethodGet, url, nil)
+}
+
+func exponentialBackoffPOST(client *http.Client, url string, comment Comment) (*http.Response, time.Duration, int64, int64, error) {
+	commentJSON, err := json.Marshal(comment)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	return exponentialBackoffDo(client, http.MethodPost, url, commentJSON)
+}
+
+// exponentialBackoffDo issues a single request over client, retrying with
+// exponential backoff (capped at 5s) on transport errors, for at most 10
+// attempts in all.
+func exponentialBackoffDo(client *http.Client, method, url string, body []byte) (*http.Response, time.Duration, int64, int64, error) {
 	backoff := 20 * time.Millisecond
 	var latency time.Duration
 	var requestSize, responseSize int64
 	for i := 0; i < 10; i++ {
-		start := time.Now()
-		commentJSON, err := json.Marshal(comment)
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewBuffer(body)
+		}
+		req, err := http.NewRequest(method, url, reqBody)
 		if err != nil {
 			return nil, 0, 0, 0, err
 		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		applyAuth(req)
 
-		resp, err := http.Post(url, "application/json", bytes.NewBuffer(commentJSON))
+		start := time.Now()
+		resp, err := client.Do(req)
 		latency = time.Since(start)
 		if err == nil {
-			// Calculate request and response sizes
-			requestSize = int64(len(commentJSON))
+			requestSize = int64(len(body))
 			if resp != nil && resp.Body != nil {
 				responseSize, _ = io.Copy(ioutil.Discard, resp.Body)
 			}
@@ -211,7 +481,3 @@ func randomString(length int) string {
 	}
 	return string(b)
 }
-
-func sortDurationSlice(slice []time.Duration) {
-	sort.Slice(slice, func(i, j int) bool { return slice[i] < slice[j] })
-}
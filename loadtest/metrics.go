@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// histogram bounds: 1 microsecond to 10 minutes, 3 significant figures of
+// precision. That range comfortably covers everything from a cache hit to a
+// badly stalled request without the bucket count blowing up.
+const (
+	histMinValue   = 1
+	histMaxValue   = int64(10 * time.Minute / time.Microsecond)
+	histSigFigures = 3
+)
+
+var snapshotPercentiles = []float64{50, 90, 95, 99, 99.9}
+
+// MetricsRecorder accumulates per-operation latency histograms shared across
+// all worker goroutines, replacing the old per-request []time.Duration
+// slice. HDR histograms record in O(1) with fixed memory, regardless of how
+// many requests are made, and report accurate percentiles across a wide
+// dynamic range without requiring a final sort.
+//
+// Each operation has two histograms: the "uncorrected" one records service
+// time (actual_finish - actual_start) as before; the "corrected" one, only
+// populated when -rate pacing is enabled, records actual_finish -
+// intended_start, so a stalled response can't hide the requests a real
+// fixed-rate client would have missed behind it (coordinated omission).
+type MetricsRecorder struct {
+	mu                  sync.Mutex
+	getHist             *hdrhistogram.Histogram
+	postHist            *hdrhistogram.Histogram
+	deleteHist          *hdrhistogram.Histogram
+	getHistCorrected    *hdrhistogram.Histogram
+	postHistCorrected   *hdrhistogram.Histogram
+	deleteHistCorrected *hdrhistogram.Histogram
+}
+
+// NewMetricsRecorder builds an empty MetricsRecorder.
+func NewMetricsRecorder() *MetricsRecorder {
+	return &MetricsRecorder{
+		getHist:             hdrhistogram.New(histMinValue, histMaxValue, histSigFigures),
+		postHist:            hdrhistogram.New(histMinValue, histMaxValue, histSigFigures),
+		deleteHist:          hdrhistogram.New(histMinValue, histMaxValue, histSigFigures),
+		getHistCorrected:    hdrhistogram.New(histMinValue, histMaxValue, histSigFigures),
+		postHistCorrected:   hdrhistogram.New(histMinValue, histMaxValue, histSigFigures),
+		deleteHistCorrected: hdrhistogram.New(histMinValue, histMaxValue, histSigFigures),
+	}
+}
+
+// RecordGet records an uncorrected GET latency sample, in microseconds.
+func (m *MetricsRecorder) RecordGet(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.getHist.RecordValue(d.Microseconds())
+}
+
+// RecordPost records an uncorrected POST latency sample, in microseconds.
+func (m *MetricsRecorder) RecordPost(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.postHist.RecordValue(d.Microseconds())
+}
+
+// RecordGetCorrected records a coordinated-omission-corrected GET latency
+// sample (actual_finish - intended_start), in microseconds.
+func (m *MetricsRecorder) RecordGetCorrected(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.getHistCorrected.RecordValue(d.Microseconds())
+}
+
+// RecordPostCorrected records a coordinated-omission-corrected POST latency
+// sample (actual_finish - intended_start), in microseconds.
+func (m *MetricsRecorder) RecordPostCorrected(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.postHistCorrected.RecordValue(d.Microseconds())
+}
+
+// RecordDelete records an uncorrected DELETE latency sample, in
+// microseconds.
+func (m *MetricsRecorder) RecordDelete(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.deleteHist.RecordValue(d.Microseconds())
+}
+
+// RecordDeleteCorrected records a coordinated-omission-corrected DELETE
+// latency sample (actual_finish - intended_start), in microseconds.
+func (m *MetricsRecorder) RecordDeleteCorrected(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.deleteHistCorrected.RecordValue(d.Microseconds())
+}
+
+// snapshot is a point-in-time copy of the uncorrected histograms, safe to
+// read without holding m.mu.
+func (m *MetricsRecorder) snapshot() (get, post, del *hdrhistogram.Histogram) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return cloneHistogram(m.getHist), cloneHistogram(m.postHist), cloneHistogram(m.deleteHist)
+}
+
+// snapshotCorrected is a point-in-time copy of the corrected histograms,
+// safe to read without holding m.mu. It is only meaningful when -rate
+// pacing was enabled; otherwise all three histograms are empty.
+func (m *MetricsRecorder) snapshotCorrected() (get, post, del *hdrhistogram.Histogram) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return cloneHistogram(m.getHistCorrected), cloneHistogram(m.postHistCorrected), cloneHistogram(m.deleteHistCorrected)
+}
+
+// cloneHistogram returns an independent copy of h. Histogram.Merge mutates
+// its receiver in place and reports the number of dropped samples, so a
+// snapshot has to merge the source into a fresh, empty histogram rather
+// than merge a histogram into itself.
+func cloneHistogram(h *hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	out := hdrhistogram.New(histMinValue, histMaxValue, histSigFigures)
+	out.Merge(h)
+	return out
+}
+
+// mergeRemote folds a -worker's reported histogram buckets (as captured by
+// histogramBuckets and shipped back over Redis by runWorker) into this
+// recorder's totals. Used by the -coordinator to combine every worker's
+// samples into one set of histograms, so the final percentiles reflect the
+// whole fleet exactly rather than an average of already-reduced per-worker
+// percentiles.
+func (m *MetricsRecorder) mergeRemote(get, post, del, getCorrected, postCorrected, deleteCorrected []hdrhistogram.Bar) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getHist.Merge(histogramFromBuckets(get))
+	m.postHist.Merge(histogramFromBuckets(post))
+	m.deleteHist.Merge(histogramFromBuckets(del))
+	m.getHistCorrected.Merge(histogramFromBuckets(getCorrected))
+	m.postHistCorrected.Merge(histogramFromBuckets(postCorrected))
+	m.deleteHistCorrected.Merge(histogramFromBuckets(deleteCorrected))
+}
+
+// histogramBuckets flattens a histogram's non-empty buckets into a form
+// that round-trips over JSON/Redis: plain (from, to, count) values a
+// -coordinator can replay into an equivalent histogram with
+// histogramFromBuckets, rather than reducing it to already-lossy
+// percentiles before it ever leaves the worker.
+func histogramBuckets(h *hdrhistogram.Histogram) []hdrhistogram.Bar {
+	var bars []hdrhistogram.Bar
+	for _, bar := range h.Distribution() {
+		if bar.Count > 0 {
+			bars = append(bars, bar)
+		}
+	}
+	return bars
+}
+
+// histogramFromBuckets rebuilds a histogram from buckets captured by
+// histogramBuckets on a remote -worker.
+func histogramFromBuckets(bars []hdrhistogram.Bar) *hdrhistogram.Histogram {
+	h := hdrhistogram.New(histMinValue, histMaxValue, histSigFigures)
+	for _, bar := range bars {
+		_ = h.RecordValues(bar.To, bar.Count)
+	}
+	return h
+}
+
+// StartSnapshotting launches a goroutine that prints rolling latency
+// percentiles every interval, until stop is closed. It returns immediately.
+func (m *MetricsRecorder) StartSnapshotting(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.printSnapshot()
+			}
+		}
+	}()
+}
+
+func (m *MetricsRecorder) printSnapshot() {
+	get, post, del := m.snapshot()
+	fmt.Printf("[snapshot %s] GET p50=%s p90=%s p95=%s p99=%s p999=%s | POST p50=%s p90=%s p95=%s p99=%s p999=%s | DELETE p50=%s p90=%s p95=%s p99=%s p999=%s\n",
+		time.Now().Format(time.RFC3339),
+		microDuration(get.ValueAtQuantile(50)), microDuration(get.ValueAtQuantile(90)),
+		microDuration(get.ValueAtQuantile(95)), microDuration(get.ValueAtQuantile(99)),
+		microDuration(get.ValueAtQuantile(99.9)),
+		microDuration(post.ValueAtQuantile(50)), microDuration(post.ValueAtQuantile(90)),
+		microDuration(post.ValueAtQuantile(95)), microDuration(post.ValueAtQuantile(99)),
+		microDuration(post.ValueAtQuantile(99.9)),
+		microDuration(del.ValueAtQuantile(50)), microDuration(del.ValueAtQuantile(90)),
+		microDuration(del.ValueAtQuantile(95)), microDuration(del.ValueAtQuantile(99)),
+		microDuration(del.ValueAtQuantile(99.9)),
+	)
+
+	if getC, postC, delC := m.snapshotCorrected(); getC.TotalCount() > 0 || postC.TotalCount() > 0 || delC.TotalCount() > 0 {
+		fmt.Printf("[snapshot %s] GET(corrected) p50=%s p90=%s p95=%s p99=%s p999=%s | POST(corrected) p50=%s p90=%s p95=%s p99=%s p999=%s | DELETE(corrected) p50=%s p90=%s p95=%s p99=%s p999=%s\n",
+			time.Now().Format(time.RFC3339),
+			microDuration(getC.ValueAtQuantile(50)), microDuration(getC.ValueAtQuantile(90)),
+			microDuration(getC.ValueAtQuantile(95)), microDuration(getC.ValueAtQuantile(99)),
+			microDuration(getC.ValueAtQuantile(99.9)),
+			microDuration(postC.ValueAtQuantile(50)), microDuration(postC.ValueAtQuantile(90)),
+			microDuration(postC.ValueAtQuantile(95)), microDuration(postC.ValueAtQuantile(99)),
+			microDuration(postC.ValueAtQuantile(99.9)),
+			microDuration(delC.ValueAtQuantile(50)), microDuration(delC.ValueAtQuantile(90)),
+			microDuration(delC.ValueAtQuantile(95)), microDuration(delC.ValueAtQuantile(99)),
+			microDuration(delC.ValueAtQuantile(99.9)),
+		)
+	}
+}
+
+func microDuration(micros int64) time.Duration {
+	return time.Duration(micros) * time.Microsecond
+}
+
+// promCollector exposes the rolling GET/POST/DELETE percentiles (and their
+// corrected counterparts, once any were recorded) as Prometheus gauges,
+// recomputed on every scrape.
+type promCollector struct {
+	recorder *MetricsRecorder
+	desc     *prometheus.Desc
+}
+
+func newPromCollector(recorder *MetricsRecorder) *promCollector {
+	return &promCollector{
+		recorder: recorder,
+		desc: prometheus.NewDesc(
+			"loadtest_latency_seconds",
+			"Observed loadtest request latency quantiles.",
+			[]string{"op", "quantile"}, nil,
+		),
+	}
+}
+
+func (c *promCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *promCollector) Collect(ch chan<- prometheus.Metric) {
+	get, post, del := c.recorder.snapshot()
+	getC, postC, delC := c.recorder.snapshotCorrected()
+	for _, p := range snapshotPercentiles {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+			microDuration(get.ValueAtQuantile(p)).Seconds(), "get", quantileLabel(p))
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+			microDuration(post.ValueAtQuantile(p)).Seconds(), "post", quantileLabel(p))
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+			microDuration(del.ValueAtQuantile(p)).Seconds(), "delete", quantileLabel(p))
+		if getC.TotalCount() > 0 {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+				microDuration(getC.ValueAtQuantile(p)).Seconds(), "get_corrected", quantileLabel(p))
+		}
+		if postC.TotalCount() > 0 {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+				microDuration(postC.ValueAtQuantile(p)).Seconds(), "post_corrected", quantileLabel(p))
+		}
+		if delC.TotalCount() > 0 {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue,
+				microDuration(delC.ValueAtQuantile(p)).Seconds(), "delete_corrected", quantileLabel(p))
+		}
+	}
+}
+
+func quantileLabel(p float64) string {
+	return fmt.Sprintf("%g", p)
+}
+
+// ServeMetrics exposes a Prometheus /metrics endpoint on addr. It runs the
+// HTTP server in a background goroutine and returns immediately; errors are
+// logged rather than fatal, since the load test itself shouldn't die because
+// the metrics endpoint couldn't bind.
+func ServeMetrics(addr string, recorder *MetricsRecorder) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(newPromCollector(recorder))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Println("metrics server error:", err)
+		}
+	}()
+}
+
+// WriteHGRM writes the final GET, POST and DELETE histograms to path in HGRM
+// (HdrHistogram percentile distribution) format, suitable for plotting with
+// HdrHistogram's standard plotting tools. When -rate pacing was enabled,
+// the coordinated-omission-corrected histograms are appended as additional
+// sections; callers should treat the corrected numbers, not the uncorrected
+// ones, as representative of what a user would see under sustained load.
+func WriteHGRM(path string, recorder *MetricsRecorder) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating hgrm output: %w", err)
+	}
+	defer f.Close()
+
+	get, post, del := recorder.snapshot()
+
+	fmt.Fprintln(f, "# GET latencies (microseconds), uncorrected (actual_finish - actual_start)")
+	if _, err := get.PercentilesPrint(f, 1, 1); err != nil {
+		return fmt.Errorf("writing GET hgrm: %w", err)
+	}
+	fmt.Fprintln(f, "\n# POST latencies (microseconds), uncorrected (actual_finish - actual_start)")
+	if _, err := post.PercentilesPrint(f, 1, 1); err != nil {
+		return fmt.Errorf("writing POST hgrm: %w", err)
+	}
+	fmt.Fprintln(f, "\n# DELETE latencies (microseconds), uncorrected (actual_finish - actual_start)")
+	if _, err := del.PercentilesPrint(f, 1, 1); err != nil {
+		return fmt.Errorf("writing DELETE hgrm: %w", err)
+	}
+
+	getC, postC, delC := recorder.snapshotCorrected()
+	if getC.TotalCount() > 0 {
+		fmt.Fprintln(f, "\n# GET latencies (microseconds), coordinated-omission corrected (actual_finish - intended_start)")
+		if _, err := getC.PercentilesPrint(f, 1, 1); err != nil {
+			return fmt.Errorf("writing corrected GET hgrm: %w", err)
+		}
+	}
+	if postC.TotalCount() > 0 {
+		fmt.Fprintln(f, "\n# POST latencies (microseconds), coordinated-omission corrected (actual_finish - intended_start)")
+		if _, err := postC.PercentilesPrint(f, 1, 1); err != nil {
+			return fmt.Errorf("writing corrected POST hgrm: %w", err)
+		}
+	}
+	if delC.TotalCount() > 0 {
+		fmt.Fprintln(f, "\n# DELETE latencies (microseconds), coordinated-omission corrected (actual_finish - intended_start)")
+		if _, err := delC.PercentilesPrint(f, 1, 1); err != nil {
+			return fmt.Errorf("writing corrected DELETE hgrm: %w", err)
+		}
+	}
+	return nil
+}
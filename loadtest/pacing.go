@@ -0,0 +1,39 @@
+package main
+
+import "time"
+
+// Pacer schedules successive operations at a fixed rate, independent of how
+// long each operation actually takes. The classic coordinated-omission bug
+// comes from measuring latency as actual_finish - actual_start: when a
+// response stalls, the worker simply starts its next request late, and the
+// requests a real fixed-rate client would have missed during the stall
+// never get recorded at all. Wait instead returns the time the slot was
+// supposed to start, so callers can record actual_finish - intended_start
+// and have the stall show up in the tail percentiles.
+type Pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// NewPacer builds a Pacer that issues one slot every interval, starting now.
+// An interval of zero or less disables pacing: Wait returns immediately and
+// always reports the current time as the intended start.
+func NewPacer(interval time.Duration) *Pacer {
+	return &Pacer{interval: interval, next: time.Now()}
+}
+
+// Wait blocks until the next scheduled slot, unless it has already passed,
+// and returns the time that slot was intended to start. Call it once per
+// operation, immediately before dispatching the request. A Pacer is not
+// safe for concurrent use; give each worker its own.
+func (p *Pacer) Wait() time.Time {
+	if p.interval <= 0 {
+		return time.Now()
+	}
+	intended := p.next
+	if now := time.Now(); now.Before(intended) {
+		time.Sleep(intended.Sub(now))
+	}
+	p.next = p.next.Add(p.interval)
+	return intended
+}
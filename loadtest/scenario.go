@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operation identifies one of the request shapes a Scenario can pick.
+type Operation string
+
+const (
+	OpGetComments Operation = "get_comments"
+	OpPostComment Operation = "post_comment"
+	OpPaginate    Operation = "paginate"
+	OpDelete      Operation = "delete"
+	OpSearch      Operation = "search"
+)
+
+// OpWeight is the weight assigned to a single operation in a scenario file.
+type OpWeight struct {
+	Op     Operation `yaml:"op" json:"op"`
+	Weight float64   `yaml:"weight" json:"weight"`
+}
+
+// RangeConfig describes a uniform [Min, Max] range, used for both payload
+// sizes (bytes) and think time (milliseconds).
+type RangeConfig struct {
+	Min int `yaml:"min" json:"min"`
+	Max int `yaml:"max" json:"max"`
+}
+
+// ScenarioConfig is the shape of a -scenario YAML/JSON file.
+type ScenarioConfig struct {
+	// RPS is the target requests/sec across all threads combined. Zero means
+	// closed-loop: threads fire the next request as soon as the previous one
+	// completes, with no pacing.
+	RPS int `yaml:"rps" json:"rps"`
+
+	// Ops is the weighted mix of operations to choose from. Weights are
+	// relative, not required to sum to 1 or 100.
+	Ops []OpWeight `yaml:"ops" json:"ops"`
+
+	// PayloadSize bounds the size (in bytes) of generated POST comment
+	// bodies.
+	PayloadSize RangeConfig `yaml:"payload_size" json:"payload_size"`
+
+	// ThinkTime bounds the pause (in milliseconds) a worker sleeps between
+	// requests, simulating a human reading the page between actions.
+	ThinkTime RangeConfig `yaml:"think_time" json:"think_time"`
+}
+
+// defaultScenarioConfig reproduces the tool's original behavior: a 50/50
+// GET/POST coin flip, no pacing, no think time, and comment bodies ~30 bytes
+// long.
+func defaultScenarioConfig() ScenarioConfig {
+	return ScenarioConfig{
+		Ops: []OpWeight{
+			{Op: OpGetComments, Weight: 1},
+			{Op: OpPostComment, Weight: 1},
+		},
+		PayloadSize: RangeConfig{Min: 30, Max: 30},
+	}
+}
+
+// loadScenarioConfig reads a -scenario file. The format is chosen by file
+// extension: .yaml/.yml is parsed as YAML, anything else as JSON.
+func loadScenarioConfig(path string) (ScenarioConfig, error) {
+	var cfg ScenarioConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("reading scenario file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("parsing scenario file: %w", err)
+	}
+	if len(cfg.Ops) == 0 {
+		return cfg, fmt.Errorf("scenario file defines no ops")
+	}
+	return cfg, nil
+}
+
+// Scenario picks which Operation a worker should perform next, according to
+// a weighted mix.
+type Scenario struct {
+	ops     []Operation
+	weights []float64
+	total   float64
+}
+
+// NewScenario builds a Scenario from a ScenarioConfig's weighted op list.
+func NewScenario(cfg ScenarioConfig) (*Scenario, error) {
+	s := &Scenario{}
+	for _, ow := range cfg.Ops {
+		if ow.Weight <= 0 {
+			continue
+		}
+		s.ops = append(s.ops, ow.Op)
+		s.weights = append(s.weights, ow.Weight)
+		s.total += ow.Weight
+	}
+	if len(s.ops) == 0 {
+		return nil, fmt.Errorf("scenario has no ops with positive weight")
+	}
+	return s, nil
+}
+
+// Next returns the next operation to perform, chosen randomly in proportion
+// to each op's configured weight.
+func (s *Scenario) Next() Operation {
+	if len(s.ops) == 1 {
+		return s.ops[0]
+	}
+	r := rand.Float64() * s.total
+	for i, w := range s.weights {
+		r -= w
+		if r <= 0 {
+			return s.ops[i]
+		}
+	}
+	return s.ops[len(s.ops)-1]
+}